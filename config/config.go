@@ -3,18 +3,18 @@ package config
 import (
 	"crypto/aes"
 	"crypto/cipher"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"io/ioutil"
+	"os"
 	"os/user"
 	"strings"
 
 	"github.com/ditcraft/client/helpers"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/crypto"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -25,98 +25,150 @@ var DitConfig ditConfig
 var demoUserAddress = "0x0000000000000000000000000000000000000000"
 var demoUserPrivateKey = "0000000000000000000000000000000000000000000000000000000000000000"
 
+// defaultAccountName is the alias used for the single account migrated from older, single-key configs
+const defaultAccountName = "default"
+
 type ditConfig struct {
-	DitCoordinator string       `json:"dit_coordinator"`
-	KNWVoting      string       `json:"knw_voting"`
-	KNWToken       string       `json:"knw_token"`
-	DitToken       string       `json:"dit_token"`
-	Currency       string       `json:"currency"`
-	DemoModeActive bool         `json:"demo_mode_active"`
-	EthereumKeys   ethereumKeys `json:"ethereum_keys"`
-	Repositories   []Repository `json:"repositories"`
+	DitCoordinator string                  `json:"dit_coordinator"`
+	KNWVoting      string                  `json:"knw_voting"`
+	KNWToken       string                  `json:"knw_token"`
+	DitToken       string                  `json:"dit_token"`
+	Currency       string                  `json:"currency"`
+	DemoModeActive bool                    `json:"demo_mode_active"`
+	Accounts       map[string]ethereumKeys `json:"accounts"`
+	DefaultAccount string                  `json:"default_account"`
+	Repositories   []Repository            `json:"repositories"`
 }
 
+// ethereumKeys no longer stores key material directly - the actual key lives in a
+// Web3 Secret Storage (keystore v3) file under ~/.ditkeys and is only referenced by path, unless
+// SignerBackend points at an external signer that holds the key itself
 type ethereumKeys struct {
-	PrivateKey string `json:"private_key"`
-	Address    string `json:"address"`
+	Address        string `json:"address"`
+	KeyPath        string `json:"key_path,omitempty"`
+	SignerBackend  string `json:"signer_backend,omitempty"`  // "" or "local", "clef", "ledger", "trezor"
+	ClefEndpoint   string `json:"clef_endpoint,omitempty"`    // IPC path or HTTP/WS URL of the clef instance
+	DerivationPath string `json:"derivation_path,omitempty"` // BIP-44 path used by the ledger/trezor backends
+	VoteKeyPath    string `json:"vote_key_path,omitempty"`   // keystore v3 file used to derive the vote-state encryption key when the signing key itself isn't available locally (clef, ledger, trezor)
 }
 
-// Repository struct, exported since its used in the ethereum package for new repositories
-type Repository struct {
-	Name            string       `json:"name"`
-	Provider        string       `json:"provider"`
-	KnowledgeLabels []string     `json:"knowledge_labels"`
-	ActiveVotes     []ActiveVote `json:"active_votes"`
+// AccountInfo is a lightweight, read-only view of a configured account
+type AccountInfo struct {
+	Name    string
+	Address string
 }
 
-// ActiveVote struct, exported since its used in the ethereum package for new votes
-type ActiveVote struct {
-	ID             int    `json:"id"`
-	KNWVoteID      int    `json:"knw_vote_id"`
-	KnowledgeLabel string `json:"knowledge_label"`
-	Choice         int    `json:"choice"`
-	Salt           int    `json:"salt"`
-	NumTokens      int    `json:"num_tokens"`
-	NumVotes       int    `json:"num_votes"`
-	NumKNW         int    `json:"num_knw"`
-	CommitEnd      int    `json:"commit_end"`
-	RevealEnd      int    `json:"reveal_end"`
-	Resolved       bool   `json:"resolved"`
-	DemoChoices    []int  `json:"demo_choices"`
-	DemoSalts      []int  `json:"demo_salts"`
+// Repository struct, exported since its used in the ethereum package for new repositories
+// ActiveVotes are never stored in the clear - EncryptedVotes holds them AES-GCM encrypted with a
+// key derived from the repository's account, see GetActiveVotes/MutateActiveVotes
+type Repository struct {
+	Name            string   `json:"name"`
+	Provider        string   `json:"provider"`
+	Account         string   `json:"account"`
+	KnowledgeLabels []string `json:"knowledge_labels"`
+	EncryptedVotes  string   `json:"encrypted_votes,omitempty"`
 }
 
 // GetPrivateKey will prompt the user for his password and return the decrypted ethereum private key
+// of the default account
 func GetPrivateKey() (string, error) {
+	return GetPrivateKeyFor(DitConfig.DefaultAccount)
+}
+
+// GetPrivateKeyFor will prompt the user for his password and return the decrypted ethereum private
+// key of the named account
+func GetPrivateKeyFor(name string) (string, error) {
+	account, exists := DitConfig.Accounts[name]
+	if !exists {
+		return "", errors.New("No account named '" + name + "' configured")
+	}
+
+	if DitConfig.DemoModeActive {
+		return demoUserPrivateKey, nil
+	}
+
 	// Prompting the user
 	helpers.PrintLine("This action requires to send a transaction to the ethereum blockchain.", 0)
-	helpers.Printf("Please provide your password to unlock your ethereum account: ", 0)
+	helpers.Printf("Please provide your password to unlock the '"+name+"' account: ", 0)
 	password, err := terminal.ReadPassword(0)
 	fmt.Printf("\n")
 	if err != nil {
 		return "", errors.New("Failed to retrieve password")
 	}
 
-	// Converting the encrypted private key from hex to bytes
-	encPrivateKey, err := hex.DecodeString(DitConfig.EthereumKeys.PrivateKey)
+	// Reading the keystore v3 file referenced by the config
+	keyJSON, err := ioutil.ReadFile(account.KeyPath)
 	if err != nil {
-		return "", errors.New("Failed to decode private key from config")
+		return "", errors.New("Failed to read keystore file")
 	}
 
 	// Decrypting the private key
-	decryptedPrivateKey, err := decrypt(encPrivateKey, string(password))
+	key, err := keystore.DecryptKey(keyJSON, string(password))
 	if err != nil {
 		return "", errors.New("Failed to decrypt the encrypted private key - wrong password?")
 	}
 
-	return string(decryptedPrivateKey), nil
+	return hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)), nil
 }
 
 // Load will load the config and set it to the exported variable "DitConfig"
 func Load() error {
-	// Retrieve the home directory of the user
-	usr, err := user.Current()
-	if err != nil {
-		return errors.New("Failed to retrieve home-directory of user")
-	}
+	var legacyBlobPrivateKey string
+	var legacySingleAccount *ethereumKeys
 
-	// Reading the config file
-	configFile, err := ioutil.ReadFile(usr.HomeDir + "/.ditconfig")
-	if err != nil {
-		if strings.Contains(err.Error(), "no such file or directory") {
-			return errors.New("Config file not found - please use '" + helpers.ColorizeCommand("setup") + "'")
+	err := withConfigLock(false, func(path string) error {
+		// Reading the config file
+		configFile, err := ioutil.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("Config file not found - please use '" + helpers.ColorizeCommand("setup") + "'")
+			}
+			return errors.New("Failed to load config file")
+		}
+
+		// Parsing the json into a public object
+		if err := json.Unmarshal(configFile, &DitConfig); err != nil {
+			return errors.New("Failed to unmarshal JSON of config file")
+		}
+
+		// Pre-keystore-v3 configs kept the encrypted key inline instead of in a keystore file
+		var legacyBlob struct {
+			EthereumKeys struct {
+				PrivateKey string `json:"private_key"`
+			} `json:"ethereum_keys"`
+		}
+		if err := json.Unmarshal(configFile, &legacyBlob); err == nil && legacyBlob.EthereumKeys.PrivateKey != "" {
+			legacyBlobPrivateKey = legacyBlob.EthereumKeys.PrivateKey
+		} else if len(DitConfig.Accounts) == 0 {
+			// Pre-multi-account configs stored a single "ethereum_keys" object instead of "accounts"
+			var legacySingle struct {
+				EthereumKeys *ethereumKeys `json:"ethereum_keys"`
+			}
+			if err := json.Unmarshal(configFile, &legacySingle); err == nil && legacySingle.EthereumKeys != nil && legacySingle.EthereumKeys.Address != "" {
+				legacySingleAccount = legacySingle.EthereumKeys
+			}
 		}
-		return errors.New("Failed to load config file")
-	}
 
-	// Parsing the json into a public object
-	err = json.Unmarshal(configFile, &DitConfig)
+		return nil
+	})
 	if err != nil {
-		return errors.New("Failed to unmarshal JSON of config file")
+		return err
 	}
 
-	// If the config is valid, it will contain an ethereum address with a length of 42
-	if len(DitConfig.EthereumKeys.Address) != 42 {
+	// Migrations persist their own changes, each acquiring the lock again for its atomic write
+	if legacyBlobPrivateKey != "" {
+		if err := migrateLegacyKey(legacyBlobPrivateKey); err != nil {
+			return err
+		}
+	} else if legacySingleAccount != nil {
+		if err := migrateSingleAccount(*legacySingleAccount); err != nil {
+			return err
+		}
+	}
+
+	// If the config is valid, its default account will exist and have a properly formatted address
+	account, exists := DitConfig.Accounts[DitConfig.DefaultAccount]
+	if !exists || len(account.Address) != 42 {
 		return errors.New("Invalid config file")
 	}
 
@@ -126,23 +178,60 @@ func Load() error {
 // Create will create a new config file
 func Create(_demoMode bool) error {
 	helpers.PrintLine("Initializing the ditClient...", 0)
-	DitConfig.DemoModeActive = _demoMode
 
-	if !DitConfig.DemoModeActive {
-		DitConfig.Currency = "xDai"
+	demoModeActive := _demoMode
+	currency := ""
+	accounts := make(map[string]ethereumKeys)
+
+	if !demoModeActive {
+		currency = "xDai"
 		helpers.PrintLine("Hint: If you just want to play around with dit, you can also use demo mode with '"+helpers.ColorizeCommand("setup --demo")+"'", 0)
 
+		ks, err := openKeyStore()
+		if err != nil {
+			return err
+		}
+
+		// Prompting the user to set a password for the private keys encryption
+		var password []byte
+		keepAsking := true
+		for keepAsking {
+			helpers.Printf("Please provide a password to encrypt your private key: ", 0)
+			password, err = terminal.ReadPassword(0)
+			fmt.Printf("\n")
+			if err != nil {
+				return errors.New("Failed to retrieve password")
+			}
+
+			// Repeating the password to make sure that there are no typos
+			helpers.Printf("Please repeat your password: ", 0)
+			passwordAgain, err := terminal.ReadPassword(0)
+			fmt.Printf("\n")
+			if err != nil {
+				return errors.New("Failed to retrieve password")
+			}
+
+			// If passwords don't match or are empty
+			if string(passwordAgain) != string(password) {
+				helpers.PrintLine("Passwords didn't match - try again!", 1)
+			} else if len(password) == 0 {
+				helpers.PrintLine("Password can't be empty - try again!", 1)
+			} else {
+				// Stop if nothing of the above is true
+				keepAsking = false
+			}
+		}
+
 		// Prompting the user for his choice on the ethereum key generation/importing
 		answerPrivateKeySelection := helpers.GetUserInputChoice("You can either (a) sample a new ethereum private-key or (b) provide your own one", "a", "b")
 
 		// Sample new ethereum Keys
 		if answerPrivateKeySelection == "a" {
-			address, privateKey, err := sampleEthereumKeys()
+			address, keyPath, err := sampleEthereumKeys(ks, string(password))
 			if err != nil {
 				return err
 			}
-			DitConfig.EthereumKeys.PrivateKey = privateKey
-			DitConfig.EthereumKeys.Address = address
+			accounts[defaultAccountName] = ethereumKeys{Address: address, KeyPath: keyPath}
 		} else {
 			// Import existing ones, prompting the user for input
 			answerPrivateKeyInput := helpers.GetUserInput("Please provide a hex-formatted ethereum private-key")
@@ -152,101 +241,210 @@ func Create(_demoMode bool) error {
 					answerPrivateKeyInput = answerPrivateKeyInput[2:]
 				}
 				// Import the ethereum private key
-				address, privateKey, err := importEthereumKey(answerPrivateKeyInput)
+				address, keyPath, err := importEthereumKey(ks, answerPrivateKeyInput, string(password))
 				if err != nil {
 					return err
 				}
 
-				DitConfig.EthereumKeys.PrivateKey = privateKey
-				DitConfig.EthereumKeys.Address = address
+				accounts[defaultAccountName] = ethereumKeys{Address: address, KeyPath: keyPath}
 			} else {
 				return errors.New("Invalid ethereum private-key")
 			}
 		}
 	} else {
 		helpers.PrintLine("Pre-funded private key was chosen due to demo mode being active", 3)
-		DitConfig.EthereumKeys.PrivateKey = demoUserPrivateKey
-		DitConfig.EthereumKeys.Address = demoUserAddress
-		DitConfig.Currency = "xDit"
-	}
-
-	// Prompting the user to set a password for the private keys encryption
-	var password []byte
-	keepAsking := true
-	for keepAsking {
-		helpers.Printf("Please provide a password to encrypt your private key: ", 0)
-		var err error
-		password, err = terminal.ReadPassword(0)
-		fmt.Printf("\n")
-		if err != nil {
-			return errors.New("Failed to retrieve password")
-		}
+		accounts[defaultAccountName] = ethereumKeys{Address: demoUserAddress}
+		currency = "xDit"
+	}
 
-		// Repeating the password to make sure that there are no typos
-		helpers.Printf("Please repeat your password: ", 0)
-		passwordAgain, err := terminal.ReadPassword(0)
-		fmt.Printf("\n")
-		if err != nil {
-			return errors.New("Failed to retrieve password")
-		}
+	// Persisting atomically, under lock, in case a second "dit setup" races this one
+	err := WithLock(func(cfg *ditConfig) error {
+		cfg.DemoModeActive = demoModeActive
+		cfg.Currency = currency
+		cfg.Accounts = accounts
+		cfg.DefaultAccount = defaultAccountName
+		cfg.Repositories = make([]Repository, 0)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
 
-		// If passwords don't match or are empty
-		if string(passwordAgain) != string(password) {
-			helpers.PrintLine("Passwords didn't match - try again!", 1)
-		} else if len(password) == 0 {
-			helpers.PrintLine("Password can't be empty - try again!", 1)
-		} else {
-			// Stop if nothing of the above is true
-			keepAsking = false
-		}
+	helpers.PrintLine("Initialization successfull", 0)
+	helpers.PrintLine("Your Ethereum Address is: "+DitConfig.Accounts[defaultAccountName].Address, 0)
+
+	return nil
+}
+
+// Save will write the current in-memory config to the file, atomically and while holding an
+// exclusive lock so a concurrent "dit" invocation can't interleave its own write
+func Save() error {
+	return withConfigLock(true, func(path string) error {
+		return persistLocked(path)
+	})
+}
+
+// AddAccount adds a new named ethereum account to the config. If source is empty a fresh private
+// key is sampled, otherwise source is treated as a hex-formatted private key or, failing that, as
+// a path to an existing keystore v3 file to import
+func AddAccount(name string, source string) error {
+	if _, exists := DitConfig.Accounts[name]; exists {
+		return errors.New("An account named '" + name + "' already exists")
 	}
 
-	// Encrypt the private keys with the password
-	encryptedPrivateKey, err := encrypt([]byte(DitConfig.EthereumKeys.PrivateKey), string(password))
+	ks, err := openKeyStore()
 	if err != nil {
-		return errors.New("Failed to encrypt ethereum private-key")
+		return err
 	}
 
-	DitConfig.EthereumKeys.PrivateKey = hex.EncodeToString(encryptedPrivateKey)
-	DitConfig.Repositories = make([]Repository, 0)
+	helpers.Printf("Please provide a password to encrypt the '"+name+"' account: ", 0)
+	password, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
+	if err != nil {
+		return errors.New("Failed to retrieve password")
+	}
 
-	// Write the config to the file
-	err = Save()
+	var address, keyPath string
+	switch {
+	case source == "":
+		address, keyPath, err = sampleEthereumKeys(ks, string(password))
+	case len(source) == 66 && strings.Contains(source, "0x"):
+		address, keyPath, err = importEthereumKey(ks, source[2:], string(password))
+	case len(source) == 64:
+		address, keyPath, err = importEthereumKey(ks, source, string(password))
+	default:
+		address, keyPath, err = importKeyStoreFile(ks, source, string(password))
+	}
 	if err != nil {
 		return err
 	}
 
-	helpers.PrintLine("Initialization successfull", 0)
-	helpers.PrintLine("Your Ethereum Address is: "+DitConfig.EthereumKeys.Address, 0)
+	// Persisting atomically, under lock, re-checking existence in case another "dit account new/import" raced us
+	return WithLock(func(cfg *ditConfig) error {
+		if _, exists := cfg.Accounts[name]; exists {
+			return errors.New("An account named '" + name + "' already exists")
+		}
 
-	return nil
+		if cfg.Accounts == nil {
+			cfg.Accounts = make(map[string]ethereumKeys)
+		}
+		cfg.Accounts[name] = ethereumKeys{Address: address, KeyPath: keyPath}
+
+		if cfg.DefaultAccount == "" {
+			cfg.DefaultAccount = name
+		}
+
+		return nil
+	})
 }
 
-// Save will write the current config object to the file
-func Save() error {
-	// Convert the config object to JSON
-	jsonBytes, err := json.Marshal(DitConfig)
+// RemoveAccount removes a named account from the config. The underlying keystore file is left
+// untouched on disk
+func RemoveAccount(name string) error {
+	if _, exists := DitConfig.Accounts[name]; !exists {
+		return errors.New("No account named '" + name + "' configured")
+	}
+
+	return WithLock(func(cfg *ditConfig) error {
+		if _, exists := cfg.Accounts[name]; !exists {
+			return errors.New("No account named '" + name + "' configured")
+		}
+
+		delete(cfg.Accounts, name)
+
+		if cfg.DefaultAccount == name {
+			cfg.DefaultAccount = ""
+			for remaining := range cfg.Accounts {
+				cfg.DefaultAccount = remaining
+				break
+			}
+		}
+
+		return nil
+	})
+}
+
+// ListAccounts returns the alias and address of every configured account
+func ListAccounts() []AccountInfo {
+	accounts := make([]AccountInfo, 0, len(DitConfig.Accounts))
+	for name, account := range DitConfig.Accounts {
+		accounts = append(accounts, AccountInfo{Name: name, Address: account.Address})
+	}
+	return accounts
+}
+
+// UseAccount sets the default account, used whenever a repository has no account of its own configured
+func UseAccount(name string) error {
+	if _, exists := DitConfig.Accounts[name]; !exists {
+		return errors.New("No account named '" + name + "' configured")
+	}
+
+	return WithLock(func(cfg *ditConfig) error {
+		if _, exists := cfg.Accounts[name]; !exists {
+			return errors.New("No account named '" + name + "' configured")
+		}
+
+		cfg.DefaultAccount = name
+		return nil
+	})
+}
+
+// Export decrypts a named account's key and re-encrypts it into a fresh keystore v3 file at
+// destPath, so it can be copied to another machine or imported into geth, MetaMask or MyCrypto
+func Export(name string, destPath string) error {
+	account, exists := DitConfig.Accounts[name]
+	if !exists {
+		return errors.New("No account named '" + name + "' configured")
+	}
+
+	keyJSON, err := ioutil.ReadFile(account.KeyPath)
 	if err != nil {
-		return errors.New("Failed to marshal JSON of config file")
+		return errors.New("Failed to read keystore file")
 	}
 
-	// Retrieve the home directory of the user
-	usr, err := user.Current()
+	helpers.Printf("Please provide the password for the '"+name+"' account: ", 0)
+	password, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
+	if err != nil {
+		return errors.New("Failed to retrieve password")
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, string(password))
 	if err != nil {
-		return errors.New("Failed to retrieve home-directory of user")
+		return errors.New("Failed to decrypt the encrypted private key - wrong password?")
 	}
 
-	// Write the above into the config file
-	err = ioutil.WriteFile(usr.HomeDir+"/.ditconfig", jsonBytes, 0644)
+	helpers.Printf("Please provide a password to encrypt the exported key: ", 0)
+	exportPassword, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
 	if err != nil {
-		return errors.New("Failed to write config file")
+		return errors.New("Failed to retrieve password")
+	}
+
+	exportedJSON, err := keystore.EncryptKey(key, string(exportPassword), keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return errors.New("Failed to encrypt exported key")
+	}
+
+	if err := ioutil.WriteFile(destPath, exportedJSON, 0600); err != nil {
+		return errors.New("Failed to write exported key file")
 	}
 
 	return nil
 }
 
-// importEthereumKey will return the private key and the address of an imported private key
-func importEthereumKey(privateKey string) (string, string, error) {
+// openKeyStore opens (and implicitly creates) the keystore v3 directory under the user's home
+func openKeyStore() (*keystore.KeyStore, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return nil, errors.New("Failed to retrieve home-directory of user")
+	}
+
+	return keystore.NewKeyStore(usr.HomeDir+"/.ditkeys", keystore.StandardScryptN, keystore.StandardScryptP), nil
+}
+
+// importEthereumKey will import a raw hex private key into the keystore and return its address and key file path
+func importEthereumKey(ks *keystore.KeyStore, privateKey string, password string) (string, string, error) {
 	helpers.PrintLine("Importing ethereum key...", 0)
 
 	// Converting the private key string into a private key object
@@ -255,51 +453,161 @@ func importEthereumKey(privateKey string) (string, string, error) {
 		return "", "", errors.New("Failed to import ethereum keys")
 	}
 
-	// Calculating the address based on the privat key object
-	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	// Encrypting the key into a keystore v3 file
+	account, err := ks.ImportECDSA(key, password)
+	if err != nil {
+		return "", "", errors.New("Failed to import ethereum keys into keystore")
+	}
+
+	return account.Address.Hex(), account.URL.Path, nil
+}
+
+// importKeyStoreFile decrypts an external keystore v3 file (e.g. from geth, MetaMask or MyCrypto)
+// and re-encrypts it into our own keystore directory
+func importKeyStoreFile(ks *keystore.KeyStore, path string, password string) (string, string, error) {
+	helpers.PrintLine("Importing external keystore file...", 0)
+
+	keyJSON, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", "", errors.New("Failed to read keystore file")
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return "", "", errors.New("Failed to decrypt the provided keystore file - wrong password?")
+	}
+
+	account, err := ks.ImportECDSA(key.PrivateKey, password)
+	if err != nil {
+		return "", "", errors.New("Failed to import ethereum keys into keystore")
+	}
+
+	return account.Address.Hex(), account.URL.Path, nil
+}
+
+// createVoteEncryptionKey returns the keystore v3 file used to derive the named account's
+// vote-state encryption key, sampling and persisting a new one the first time it's needed. This is
+// used for accounts whose signing key never lives in a local keystore file (clef/ledger/trezor), so
+// vote-state encryption still has password-unlockable key material to derive from
+func createVoteEncryptionKey(name string, password string) (string, error) {
+	if account, exists := DitConfig.Accounts[name]; exists && account.VoteKeyPath != "" {
+		return account.VoteKeyPath, nil
+	}
+
+	ks, err := openKeyStore()
+	if err != nil {
+		return "", err
+	}
 
-	// Converting the private key to string
-	privateKey = hex.EncodeToString(key.D.Bytes())
+	_, keyPath, err := sampleEthereumKeys(ks, password)
+	if err != nil {
+		return "", err
+	}
+
+	var resolvedKeyPath string
+	err = WithLock(func(cfg *ditConfig) error {
+		account, exists := cfg.Accounts[name]
+		if !exists {
+			return errors.New("No account named '" + name + "' configured")
+		}
+		if account.VoteKeyPath == "" {
+			account.VoteKeyPath = keyPath
+			cfg.Accounts[name] = account
+		}
+		resolvedKeyPath = cfg.Accounts[name].VoteKeyPath
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
 
-	return address, privateKey, err
+	return resolvedKeyPath, nil
 }
 
-func sampleEthereumKeys() (string, string, error) {
+func sampleEthereumKeys(ks *keystore.KeyStore, password string) (string, string, error) {
 	helpers.PrintLine("Sampling ethereum key...", 0)
 
-	// Sampling a new private key
-	key, err := crypto.GenerateKey()
+	// Sampling a new private key and encrypting it into a keystore v3 file
+	account, err := ks.NewAccount(password)
 	if err != nil {
 		return "", "", errors.New("Failed to generate ethereum keys")
 	}
 
-	// Calculating the address based on the privat key object
-	address := crypto.PubkeyToAddress(key.PublicKey).Hex()
+	return account.Address.Hex(), account.URL.Path, nil
+}
 
-	// Converting the private key to string
-	privateKey := hex.EncodeToString(key.D.Bytes())
+// migrateLegacyKey re-encrypts a pre-keystore-v3 config (SHA256-derived AES-GCM blob) into a
+// standard Web3 Secret Storage file, so old configs keep working after upgrading the client
+func migrateLegacyKey(legacyPrivateKey string) error {
+	helpers.PrintLine("Your account was created with an older, insecure key format - upgrading it to the standard keystore format.", 2)
+	helpers.Printf("Please provide your current password to unlock your ethereum account: ", 0)
+	password, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
+	if err != nil {
+		return errors.New("Failed to retrieve password")
+	}
 
-	return address, privateKey, err
-}
+	// Converting the encrypted private key from hex to bytes
+	encPrivateKey, err := hex.DecodeString(legacyPrivateKey)
+	if err != nil {
+		return errors.New("Failed to decode private key from config")
+	}
 
-// from: https://www.thepolyglotdeveloper.com/2018/02/encrypt-decrypt-data-golang-application-crypto-packages/
-func encrypt(data []byte, passphrase string) ([]byte, error) {
-	block, _ := aes.NewCipher([]byte(createHash(passphrase)))
-	gcm, err := cipher.NewGCM(block)
+	// Decrypting the private key with the legacy scheme
+	decryptedPrivateKey, err := legacyDecrypt(encPrivateKey, string(password))
 	if err != nil {
-		return nil, err
+		return errors.New("Failed to decrypt the encrypted private key - wrong password?")
 	}
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
+
+	key, err := crypto.HexToECDSA(string(decryptedPrivateKey))
+	if err != nil {
+		return errors.New("Failed to parse legacy private key")
+	}
+
+	ks, err := openKeyStore()
+	if err != nil {
+		return err
+	}
+
+	account, err := ks.ImportECDSA(key, string(password))
+	if err != nil {
+		return errors.New("Failed to import legacy private key into keystore")
+	}
+
+	err = WithLock(func(cfg *ditConfig) error {
+		if cfg.Accounts == nil {
+			cfg.Accounts = make(map[string]ethereumKeys)
+		}
+		cfg.Accounts[defaultAccountName] = ethereumKeys{Address: account.Address.Hex(), KeyPath: account.URL.Path}
+		cfg.DefaultAccount = defaultAccountName
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	ciphertext := gcm.Seal(nonce, nonce, data, nil)
-	return ciphertext, nil
+
+	helpers.PrintLine("Your account was upgraded to the standard keystore format", 0)
+	return nil
+}
+
+// migrateSingleAccount moves a pre-multi-account config's lone "ethereum_keys" entry into the
+// new "accounts" map under the default alias
+func migrateSingleAccount(account ethereumKeys) error {
+	return WithLock(func(cfg *ditConfig) error {
+		if cfg.Accounts == nil {
+			cfg.Accounts = make(map[string]ethereumKeys)
+		}
+		cfg.Accounts[defaultAccountName] = account
+		cfg.DefaultAccount = defaultAccountName
+		return nil
+	})
 }
 
+// legacyDecrypt reverses the pre-keystore-v3 encryption scheme, kept around solely so
+// migrateLegacyKey can read old config files
 // from: https://www.thepolyglotdeveloper.com/2018/02/encrypt-decrypt-data-golang-application-crypto-packages/
-func decrypt(data []byte, passphrase string) ([]byte, error) {
-	key := []byte(createHash(passphrase))
+func legacyDecrypt(data []byte, passphrase string) ([]byte, error) {
+	key := []byte(legacyCreateHash(passphrase))
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -317,7 +625,7 @@ func decrypt(data []byte, passphrase string) ([]byte, error) {
 	return plaintext, err
 }
 
-func createHash(key string) string {
+func legacyCreateHash(key string) string {
 	hasher := sha256.New()
 	hasher.Write([]byte(key))
 	return hex.EncodeToString(hasher.Sum(nil))[0:32]
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"syscall"
+)
+
+// configPath returns the absolute path of the user's config file
+func configPath() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", errors.New("Failed to retrieve home-directory of user")
+	}
+
+	return usr.HomeDir + "/.ditconfig", nil
+}
+
+// withConfigLock opens (and implicitly creates) a ".lock" file beside the config file, takes an
+// advisory flock on it for the duration of fn and releases it afterwards. The lock lives on a
+// separate file because persistLocked replaces the config file by rename, which would leave a lock
+// on the config file itself attached to the old inode. Use exclusive for any read-modify-write, and
+// a shared lock for plain reads so they don't tear a concurrent write
+func withConfigLock(exclusive bool, fn func(path string) error) error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+
+	lockFile, err := os.OpenFile(path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return errors.New("Failed to open config file")
+	}
+	defer lockFile.Close()
+
+	lockType := syscall.LOCK_SH
+	if exclusive {
+		lockType = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), lockType); err != nil {
+		return errors.New("Failed to lock config file")
+	}
+	defer syscall.Flock(int(lockFile.Fd()), syscall.LOCK_UN)
+
+	return fn(path)
+}
+
+// WithLock locks the config file exclusively, reloads it from disk so a change written by a
+// concurrent "dit" invocation isn't clobbered, lets fn mutate the result and atomically persists
+// it. Every command that mutates the config should go through this instead of calling Save()
+// directly on an already-mutated DitConfig
+func WithLock(fn func(*ditConfig) error) error {
+	return withConfigLock(true, func(path string) error {
+		configFile, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.New("Failed to read config file")
+		}
+
+		if len(configFile) > 0 {
+			if err := json.Unmarshal(configFile, &DitConfig); err != nil {
+				return errors.New("Failed to unmarshal JSON of config file")
+			}
+		}
+
+		if err := fn(&DitConfig); err != nil {
+			return err
+		}
+
+		return persistLocked(path)
+	})
+}
+
+// persistLocked marshals the in-memory DitConfig and atomically replaces the config file at path
+// via a temp-file-then-rename swap, so a crash or a second writer can never leave a half-written
+// config behind. Callers must already hold the file lock
+func persistLocked(path string) error {
+	jsonBytes, err := json.Marshal(DitConfig)
+	if err != nil {
+		return errors.New("Failed to marshal JSON of config file")
+	}
+
+	tmpFile, err := ioutil.TempFile(filepath.Dir(path), ".ditconfig-*.tmp")
+	if err != nil {
+		return errors.New("Failed to create temporary config file")
+	}
+	tmpPath := tmpFile.Name()
+
+	if _, err := tmpFile.Write(jsonBytes); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return errors.New("Failed to write temporary config file")
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return errors.New("Failed to flush temporary config file")
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return errors.New("Failed to close temporary config file")
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return errors.New("Failed to replace config file")
+	}
+
+	return nil
+}
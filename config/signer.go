@@ -0,0 +1,232 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+
+	"github.com/ditcraft/client/helpers"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/usbwallet"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rpc"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Signer abstracts over where a private key actually lives, so call sites never have to hold raw
+// key material in memory - a signer may decrypt a local keystore file, delegate to an external
+// Clef process, or drive a connected hardware wallet
+type Signer interface {
+	SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	Address() common.Address
+}
+
+// GetSigner returns a Signer for the default account
+func GetSigner() (Signer, error) {
+	return GetSignerFor(DitConfig.DefaultAccount)
+}
+
+// GetSignerFor returns a Signer for the named account, picking the backend implementation
+// according to the account's configured SignerBackend
+func GetSignerFor(name string) (Signer, error) {
+	account, exists := DitConfig.Accounts[name]
+	if !exists {
+		return nil, errors.New("No account named '" + name + "' configured")
+	}
+
+	switch account.SignerBackend {
+	case "", "local":
+		if DitConfig.DemoModeActive {
+			return newDemoSigner(), nil
+		}
+
+		helpers.PrintLine("This action requires to send a transaction to the ethereum blockchain.", 0)
+		helpers.Printf("Please provide your password to unlock the '"+name+"' account: ", 0)
+		password, err := terminal.ReadPassword(0)
+		fmt.Printf("\n")
+		if err != nil {
+			return nil, errors.New("Failed to retrieve password")
+		}
+
+		return newLocalSigner(account, string(password))
+	case "clef":
+		return newClefSigner(account)
+	case "ledger":
+		return newHardwareSigner("ledger", account.DerivationPath)
+	case "trezor":
+		return newHardwareSigner("trezor", account.DerivationPath)
+	default:
+		return nil, errors.New("Unknown signer backend: " + account.SignerBackend)
+	}
+}
+
+// localSigner decrypts a keystore v3 file and keeps the private key in memory for the lifetime
+// of a single command
+type localSigner struct {
+	address    common.Address
+	privateKey *ecdsa.PrivateKey
+}
+
+func newLocalSigner(account ethereumKeys, password string) (*localSigner, error) {
+	keyJSON, err := ioutil.ReadFile(account.KeyPath)
+	if err != nil {
+		return nil, errors.New("Failed to read keystore file")
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt the encrypted private key - wrong password?")
+	}
+
+	return &localSigner{address: key.Address, privateKey: key.PrivateKey}, nil
+}
+
+func (s *localSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *localSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.NewEIP155Signer(chainID), s.privateKey)
+}
+
+// clefSignTxArgs mirrors the JSON shape Clef's "account_signTransaction" method expects
+type clefSignTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      hexutil.Uint64  `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    hexutil.Uint64  `json:"nonce"`
+	Data     hexutil.Bytes   `json:"data"`
+}
+
+type clefSignTxResult struct {
+	Tx *types.Transaction `json:"tx"`
+}
+
+// clefSigner delegates signing to an external Clef process over its JSON-RPC interface (IPC or
+// HTTP), so the private key never enters this process at all
+type clefSigner struct {
+	address common.Address
+	client  *rpc.Client
+}
+
+func newClefSigner(account ethereumKeys) (*clefSigner, error) {
+	if account.ClefEndpoint == "" {
+		return nil, errors.New("No clef_endpoint configured for this account")
+	}
+
+	client, err := rpc.Dial(account.ClefEndpoint)
+	if err != nil {
+		return nil, errors.New("Failed to connect to clef signer")
+	}
+
+	return &clefSigner{address: common.HexToAddress(account.Address), client: client}, nil
+}
+
+func (s *clefSigner) Address() common.Address {
+	return s.address
+}
+
+func (s *clefSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	args := &clefSignTxArgs{
+		From:     s.address,
+		To:       tx.To(),
+		Gas:      hexutil.Uint64(tx.Gas()),
+		GasPrice: (*hexutil.Big)(tx.GasPrice()),
+		Value:    (*hexutil.Big)(tx.Value()),
+		Nonce:    hexutil.Uint64(tx.Nonce()),
+		Data:     tx.Data(),
+	}
+
+	var result clefSignTxResult
+	if err := s.client.Call(&result, "account_signTransaction", args, ""); err != nil {
+		return nil, errors.New("Clef refused to sign the transaction: " + err.Error())
+	}
+
+	return result.Tx, nil
+}
+
+// hardwareSigner drives a Ledger or Trezor device via go-ethereum's usbwallet hub, so the key
+// never leaves the device
+type hardwareSigner struct {
+	wallet  accounts.Wallet
+	account accounts.Account
+}
+
+func newHardwareSigner(kind string, derivationPath string) (*hardwareSigner, error) {
+	var hub *usbwallet.Hub
+	var err error
+	switch kind {
+	case "ledger":
+		hub, err = usbwallet.NewLedgerHub()
+	case "trezor":
+		hub, err = usbwallet.NewTrezorHubWithHID()
+	default:
+		return nil, errors.New("Unknown hardware wallet type: " + kind)
+	}
+	if err != nil {
+		return nil, errors.New("Failed to open hardware wallet")
+	}
+
+	wallets := hub.Wallets()
+	if len(wallets) == 0 {
+		return nil, errors.New("No hardware wallet found - is it connected and unlocked?")
+	}
+
+	wallet := wallets[0]
+	if err := wallet.Open(""); err != nil {
+		return nil, errors.New("Failed to open hardware wallet")
+	}
+
+	if derivationPath == "" {
+		derivationPath = accounts.DefaultBaseDerivationPath.String()
+	}
+	path, err := accounts.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return nil, errors.New("Invalid derivation path")
+	}
+
+	account, err := wallet.Derive(path, true)
+	if err != nil {
+		return nil, errors.New("Failed to derive account from hardware wallet")
+	}
+
+	return &hardwareSigner{wallet: wallet, account: account}, nil
+}
+
+func (s *hardwareSigner) Address() common.Address {
+	return s.account.Address
+}
+
+func (s *hardwareSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return s.wallet.SignTx(s.account, tx, chainID)
+}
+
+// demoSigner is used only in demo mode.
+// It signs with the well-known pre-funded demo key, same as the raw-key path did before the Signer
+// abstraction was introduced
+type demoSigner struct{}
+
+func newDemoSigner() *demoSigner {
+	return &demoSigner{}
+}
+
+func (s *demoSigner) Address() common.Address {
+	return common.HexToAddress(demoUserAddress)
+}
+
+func (s *demoSigner) SignTx(tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := crypto.HexToECDSA(demoUserPrivateKey)
+	if err != nil {
+		return nil, errors.New("Failed to parse demo private key")
+	}
+
+	return types.SignTx(tx, types.NewEIP155Signer(chainID), key)
+}
@@ -0,0 +1,251 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/ditcraft/client/helpers"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// ActiveVote struct, exported since its used in the ethereum package for new votes
+type ActiveVote struct {
+	ID             int    `json:"id"`
+	KNWVoteID      int    `json:"knw_vote_id"`
+	KnowledgeLabel string `json:"knowledge_label"`
+	Choice         int    `json:"choice"`
+	Salt           int    `json:"salt"`
+	NumTokens      int    `json:"num_tokens"`
+	NumVotes       int    `json:"num_votes"`
+	NumKNW         int    `json:"num_knw"`
+	CommitEnd      int    `json:"commit_end"`
+	RevealEnd      int    `json:"reveal_end"`
+	Resolved       bool   `json:"resolved"`
+	DemoChoices    []int  `json:"demo_choices"`
+	DemoSalts      []int  `json:"demo_salts"`
+}
+
+// GetActiveVotes decrypts and returns the active votes of a repository. The commit-reveal choice
+// and salt never sit on disk in the clear, so reading stored vote state requires the repository
+// account's password; a repository without stored vote state returns an empty list without prompting
+func GetActiveVotes(repoName string) ([]ActiveVote, error) {
+	index := findRepositoryIndex(&DitConfig, repoName)
+	if index == -1 {
+		return nil, errors.New("No repository named '" + repoName + "' configured")
+	}
+	repo := DitConfig.Repositories[index]
+
+	if repo.EncryptedVotes == "" {
+		return []ActiveVote{}, nil
+	}
+
+	accountName, account, err := repositoryAccount(repo)
+	if err != nil {
+		return nil, err
+	}
+
+	helpers.Printf("Please provide your password to unlock the vote state of '"+repoName+"': ", 0)
+	password, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
+	if err != nil {
+		return nil, errors.New("Failed to retrieve password")
+	}
+
+	key, err := voteEncryptionKey(accountName, account, string(password))
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := hex.DecodeString(repo.EncryptedVotes)
+	if err != nil {
+		return nil, errors.New("Failed to decode vote state")
+	}
+
+	plaintext, err := aesGCMDecrypt(ciphertext, key)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt vote state - wrong password?")
+	}
+
+	var votes []ActiveVote
+	if err := json.Unmarshal(plaintext, &votes); err != nil {
+		return nil, errors.New("Failed to unmarshal vote state")
+	}
+
+	return votes, nil
+}
+
+// MutateActiveVotes decrypts a repository's vote state, lets mutate transform it, then
+// re-encrypts and atomically persists the result through WithLock, so "dit vote"/"dit propose"
+// can update vote state without racing a concurrent "dit" invocation. The decrypt/mutate/encrypt
+// sequence runs inside the WithLock closure so it always operates on the freshly-reloaded config,
+// not the state read before the lock was taken - otherwise a concurrent writer's update could be
+// silently lost
+func MutateActiveVotes(repoName string, mutate func([]ActiveVote) ([]ActiveVote, error)) error {
+	index := findRepositoryIndex(&DitConfig, repoName)
+	if index == -1 {
+		return errors.New("No repository named '" + repoName + "' configured")
+	}
+
+	accountName, account, err := repositoryAccount(DitConfig.Repositories[index])
+	if err != nil {
+		return err
+	}
+
+	helpers.Printf("Please confirm your password to save the vote state of '"+repoName+"': ", 0)
+	password, err := terminal.ReadPassword(0)
+	fmt.Printf("\n")
+	if err != nil {
+		return errors.New("Failed to retrieve password")
+	}
+
+	key, err := voteEncryptionKey(accountName, account, string(password))
+	if err != nil {
+		return err
+	}
+
+	return WithLock(func(cfg *ditConfig) error {
+		i := findRepositoryIndex(cfg, repoName)
+		if i == -1 {
+			return errors.New("No repository named '" + repoName + "' configured")
+		}
+
+		var votes []ActiveVote
+		if cfg.Repositories[i].EncryptedVotes != "" {
+			ciphertext, err := hex.DecodeString(cfg.Repositories[i].EncryptedVotes)
+			if err != nil {
+				return errors.New("Failed to decode vote state")
+			}
+
+			plaintext, err := aesGCMDecrypt(ciphertext, key)
+			if err != nil {
+				return errors.New("Failed to decrypt vote state - wrong password?")
+			}
+
+			if err := json.Unmarshal(plaintext, &votes); err != nil {
+				return errors.New("Failed to unmarshal vote state")
+			}
+		}
+
+		updated, err := mutate(votes)
+		if err != nil {
+			return err
+		}
+
+		plaintext, err := json.Marshal(updated)
+		if err != nil {
+			return errors.New("Failed to marshal vote state")
+		}
+
+		ciphertext, err := aesGCMEncrypt(plaintext, key)
+		if err != nil {
+			return errors.New("Failed to encrypt vote state")
+		}
+
+		cfg.Repositories[i].EncryptedVotes = hex.EncodeToString(ciphertext)
+		return nil
+	})
+}
+
+// findRepositoryIndex returns the index of the repository with the given name, or -1
+func findRepositoryIndex(cfg *ditConfig, name string) int {
+	for i := range cfg.Repositories {
+		if cfg.Repositories[i].Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// repositoryAccount resolves the name and ethereumKeys a repository's vote state is encrypted
+// for, falling back to the default account if the repository has none of its own configured
+func repositoryAccount(repo Repository) (string, ethereumKeys, error) {
+	name := repo.Account
+	if name == "" {
+		name = DitConfig.DefaultAccount
+	}
+
+	account, exists := DitConfig.Accounts[name]
+	if !exists {
+		return "", ethereumKeys{}, errors.New("No account named '" + name + "' configured")
+	}
+
+	return name, account, nil
+}
+
+// voteEncryptionKey derives a 32-byte symmetric key for vote-state encryption from the same
+// passphrase-unlocked key material used to sign transactions, so a stolen .ditconfig alone can't
+// reveal a pending vote's choice or reveal-salt. Demo mode derives the key from the well-known
+// demo private key, without a password. Accounts signing through clef/ledger/trezor keep their
+// signing key outside any local keystore file, so they fall back to a separate keystore file
+// (VoteKeyPath) that is independent of the signing key
+func voteEncryptionKey(name string, account ethereumKeys, password string) ([]byte, error) {
+	if DitConfig.DemoModeActive {
+		hash := sha256.Sum256([]byte(demoUserPrivateKey))
+		return hash[:], nil
+	}
+
+	keyPath := account.KeyPath
+	if keyPath == "" {
+		var err error
+		keyPath, err = createVoteEncryptionKey(name, password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	keyJSON, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, errors.New("Failed to read keystore file")
+	}
+
+	key, err := keystore.DecryptKey(keyJSON, password)
+	if err != nil {
+		return nil, errors.New("Failed to decrypt the encrypted private key - wrong password?")
+	}
+
+	hash := sha256.Sum256(crypto.FromECDSA(key.PrivateKey))
+	return hash[:], nil
+}
+
+func aesGCMEncrypt(plaintext []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func aesGCMDecrypt(ciphertext []byte, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonceSize := gcm.NonceSize()
+	if len(ciphertext) < nonceSize {
+		return nil, errors.New("Vote state ciphertext is too short")
+	}
+	nonce, data := ciphertext[:nonceSize], ciphertext[nonceSize:]
+	return gcm.Open(nil, nonce, data, nil)
+}